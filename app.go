@@ -1,16 +1,20 @@
 package gear
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/textproto"
+	"os"
+	"os/signal"
 	"reflect"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 // Version is Gear's version
@@ -186,6 +190,14 @@ func (s *ServerListener) Wait() error {
 type App struct {
 	middleware []Middleware
 	pool       sync.Pool
+	// shuttingDown is set to 1 once Shutdown or Close has been called, so
+	// ServeHTTP can refuse new requests while letting in-flight ones finish.
+	shuttingDown int32
+	// inflight counts requests currently being served by ServeHTTP. Shutdown
+	// polls it down to 0 before closing the listener, so that requests
+	// arriving during the drain window still reach the shuttingDown check
+	// and get a 503, instead of being refused at the TCP level.
+	inflight int64
 
 	// OnError is default ctx error handler.
 	// Override it for yourself.
@@ -194,6 +206,14 @@ type App struct {
 	// ErrorLog specifies an optional logger for app's errors. Default to nil
 	ErrorLog *log.Logger
 	Server   *http.Server
+	// Recover configures how the app recovers from a panic raised while
+	// processing a request. See RecoverOptions.
+	Recover RecoverOptions
+
+	// acmeServer is the ACME HTTP-01 challenge server started by
+	// ListenAutoTLS, if any, kept around so Shutdown/Close can stop it
+	// alongside Server instead of leaking it for the process lifetime.
+	acmeServer *http.Server
 }
 
 // New creates an instance of App.
@@ -276,6 +296,84 @@ func (app *App) Start(addr ...string) *ServerListener {
 	return &ServerListener{l, c}
 }
 
+// RegisterOnShutdown registers a function to call on Shutdown.
+// It follows the semantics of http.Server.RegisterOnShutdown.
+func (app *App) RegisterOnShutdown(f func()) {
+	app.Server.RegisterOnShutdown(f)
+}
+
+// Shutdown gracefully shuts down the app without interrupting any active
+// connections, the same way http.Server.Shutdown does. New requests are
+// refused with 503 as soon as Shutdown is called, while in-flight
+// middleware chains and afterHooks are allowed to complete.
+//
+// http.Server.Shutdown closes the listener before doing anything else, so
+// calling it right away would refuse new connections at the TCP level
+// instead of routing them to the 503 path. Shutdown therefore waits for
+// in-flight requests to drain (or ctx to expire) before handing off to
+// Server.Shutdown, keeping the listener open long enough for requests that
+// arrive during that window to reach serveHandler.ServeHTTP's 503 check.
+func (app *App) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&app.shuttingDown, 1)
+	app.waitInflight(ctx)
+
+	if app.acmeServer != nil {
+		app.acmeServer.Shutdown(ctx)
+	}
+	return app.Server.Shutdown(ctx)
+}
+
+// waitInflight blocks until no request is currently being served, or ctx is
+// done, whichever comes first.
+func (app *App) waitInflight(ctx context.Context) {
+	const pollInterval = 10 * time.Millisecond
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&app.inflight) > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the app's underlying listener(s) immediately, the same way
+// http.Server.Close does.
+func (app *App) Close() error {
+	atomic.StoreInt32(&app.shuttingDown, 1)
+	if app.acmeServer != nil {
+		app.acmeServer.Close()
+	}
+	return app.Server.Close()
+}
+
+// ListenWithGracefulShutdown starts the HTTP server and blocks until a
+// SIGINT or SIGTERM is received, then gracefully shuts down the server,
+// giving in-flight requests up to timeout to finish.
+func (app *App) ListenWithGracefulShutdown(addr string, timeout time.Duration) error {
+	app.Server.Addr = addr
+	app.Server.Handler = app.toServeHandler()
+	if app.ErrorLog != nil {
+		app.Server.ErrorLog = app.ErrorLog
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- app.Server.ListenAndServe() }()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-sig:
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return app.Shutdown(ctx)
+	}
+}
+
 // Error writes error to underlayer logging system (ErrorLog).
 func (app *App) Error(err error) {
 	if !isNil(err) {
@@ -292,23 +390,58 @@ type serveHandler struct {
 	middleware []Middleware
 }
 
+// ctxRelease holds the release-to-pool callback for every in-flight
+// Context, keyed by its pointer. Context.Upgrade claims it via takeRelease
+// so that returning ctx to app.pool is deferred until the websocket
+// connection it handed off is closed, instead of racing the caller's
+// read/write goroutine as soon as the upgrading middleware returns.
+var ctxRelease sync.Map
+
+// takeRelease removes and returns ctx's release callback, if it hasn't
+// already been claimed (by a prior call from this same function, e.g. by
+// Context.Upgrade racing serveHandler.ServeHTTP's defer).
+func takeRelease(ctx *Context) (func(), bool) {
+	v, ok := ctxRelease.LoadAndDelete(ctx)
+	if !ok {
+		return nil, false
+	}
+	return v.(func()), true
+}
+
 func (h *serveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var err error
 	ctx := h.app.pool.Get().(*Context)
 	ctx.reset(w, r)
+	ctxRelease.Store(ctx, func() {
+		ctx.reset(nil, nil)
+		h.app.pool.Put(ctx)
+	})
+	atomic.AddInt64(&h.app.inflight, 1)
 
 	// reuse Context instance, recover panic error
 	defer func() {
-		if err := recover(); err != nil {
-			httprequest, _ := httputil.DumpRequest(ctx.Req, false)
-			ctx.Error(&Error{Code: 500, Msg: http.StatusText(500)})
-			h.app.Error(fmt.Errorf("panic recovered: %s; %s",
-				err, strings.Replace(string(httprequest), "\n", "\\n", -1)))
+		if rec := recover(); rec != nil {
+			h.app.recover(ctx, rec)
+		}
+		atomic.AddInt64(&h.app.inflight, -1)
+		// a successful ctx.Upgrade already claimed (and will run) this
+		// release itself, once its Conn is closed.
+		if release, ok := takeRelease(ctx); ok {
+			release()
 		}
-		ctx.reset(nil, nil)
-		h.app.pool.Put(ctx)
 	}()
 
+	// refuse new requests with 503 while the app is shutting down; the
+	// deferred release above still resets this pooled ctx and returns it to
+	// app.pool.
+	if atomic.LoadInt32(&h.app.shuttingDown) == 1 {
+		ctx.ended = true
+		ctx.Status(http.StatusServiceUnavailable)
+		ctx.String(http.StatusText(http.StatusServiceUnavailable))
+		ctx.Res.respond()
+		return
+	}
+
 	// process app middleware
 	for _, handle := range h.middleware {
 		if err = handle(ctx); !isNil(err) {
@@ -322,8 +455,10 @@ func (h *serveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// ensure that ended is true after middleware process finished.
 	ctx.ended = true
 	if !isNil(err) {
-		ctx.Type("text")     // reset Content-Type, but you can set it in OnError again.
-		ctx.afterHooks = nil // clear afterHooks when error
+		ctx.Type("text") // reset Content-Type, but you can set it in OnError again.
+		// afterHooks (used by e.g. metrics/tracing middleware to record the
+		// final status code) must still run on the error path, so they are
+		// left untouched here instead of being discarded.
 		// process middleware error with OnError
 		if ctxErr := h.app.OnError(ctx, err); ctxErr != nil {
 			ctx.Status(ctxErr.Status())
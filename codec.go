@@ -0,0 +1,209 @@
+package gear
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec encodes and decodes request/response bodies for a given
+// Content-Type, so ctx.Bind and ctx.Send aren't hard-wired to JSON.
+type Codec interface {
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{}
+	// codecOrder tracks registration order, so Accept: */* negotiation
+	// (which falls back to the first offer) is deterministic instead of
+	// depending on Go's randomized map iteration order.
+	codecOrder []string
+)
+
+func init() {
+	RegisterCodec(MIMEApplicationJSON, jsonCodec{})
+	RegisterCodec(MIMEApplicationXML, xmlCodec{})
+	RegisterCodec(MIMEApplicationProtobuf, protobufCodec{})
+	RegisterCodec(MIMEApplicationMsgpack, msgpackCodec{})
+}
+
+// RegisterCodec registers c as the Codec to use for the given mime type,
+// replacing any previously registered codec for it. It's safe to call from
+// an init function. Registration order determines preference when a
+// request negotiates a wildcard Accept such as "*/*".
+func RegisterCodec(mime string, c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if _, exists := codecs[mime]; !exists {
+		codecOrder = append(codecOrder, mime)
+	}
+	codecs[mime] = c
+}
+
+func getCodec(mime string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[mime]
+	return c, ok
+}
+
+func registeredMimes() []string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	mimes := make([]string, len(codecOrder))
+	copy(mimes, codecOrder)
+	return mimes
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) }
+func (jsonCodec) Decode(r io.Reader, v interface{}) error { return json.NewDecoder(r).Decode(v) }
+
+type xmlCodec struct{}
+
+func (xmlCodec) Encode(w io.Writer, v interface{}) error { return xml.NewEncoder(w).Encode(v) }
+func (xmlCodec) Decode(r io.Reader, v interface{}) error { return xml.NewDecoder(r).Decode(v) }
+
+type protobufCodec struct{}
+
+func (protobufCodec) Encode(w io.Writer, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return NewAppError("protobuf codec: value does not implement proto.Message")
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (protobufCodec) Decode(r io.Reader, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return NewAppError("protobuf codec: value does not implement proto.Message")
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Encode(w io.Writer, v interface{}) error { return msgpack.NewEncoder(w).Encode(v) }
+func (msgpackCodec) Decode(r io.Reader, v interface{}) error { return msgpack.NewDecoder(r).Decode(v) }
+
+// Bind decodes the request body into v, picking a Codec by inspecting the
+// request's Content-Type header. It returns a 415 *Error if no codec is
+// registered for that type.
+func (ctx *Context) Bind(v interface{}) error {
+	mime := mimeOf(ctx.Req.Header.Get(HeaderContentType))
+	if mime == "" {
+		mime = MIMEApplicationJSON
+	}
+	c, ok := getCodec(mime)
+	if !ok {
+		return &Error{Code: 415, Msg: "unsupported content type: " + mime}
+	}
+	return c.Decode(ctx.Req.Body, v)
+}
+
+// Send negotiates a response Content-Type from the request's Accept header
+// against the registered codecs, encodes v with the matching Codec, and
+// writes it with the given status code.
+func (ctx *Context) Send(status int, v interface{}) error {
+	mime := NegotiateContentType(ctx.Req, registeredMimes(), MIMEApplicationJSON)
+	c, ok := getCodec(mime)
+	if !ok {
+		return &Error{Code: 500, Msg: "no codec registered for: " + mime}
+	}
+	ctx.Type(mime)
+	ctx.Status(status)
+	return c.Encode(ctx.Res, v)
+}
+
+// mimeOf strips any ";charset=..." parameters off a Content-Type header
+// value.
+func mimeOf(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.TrimSpace(contentType)
+}
+
+type acceptOffer struct {
+	mime string
+	q    float64
+}
+
+// NegotiateContentType runs Accept header q-value negotiation against
+// offers, and returns the best match, or defaultOffer if the request has
+// no Accept header or none of offers is acceptable. A "*/*" entry matches
+// the first of offers.
+func NegotiateContentType(r *http.Request, offers []string, defaultOffer string) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return defaultOffer
+	}
+
+	var parsed []acceptOffer
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		mime, q := part, 1.0
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			mime = strings.TrimSpace(part[:i])
+			for _, param := range strings.Split(part[i+1:], ";") {
+				param = strings.TrimSpace(param)
+				if v, ok := strings.CutPrefix(param, "q="); ok {
+					if f, err := strconv.ParseFloat(v, 64); err == nil {
+						q = f
+					}
+				}
+			}
+		}
+		parsed = append(parsed, acceptOffer{mime: mime, q: q})
+	}
+	sort.SliceStable(parsed, func(i, j int) bool { return parsed[i].q > parsed[j].q })
+
+	for _, a := range parsed {
+		if a.q <= 0 {
+			continue
+		}
+		if a.mime == "*/*" {
+			if len(offers) > 0 {
+				return offers[0]
+			}
+			continue
+		}
+		for _, offer := range offers {
+			if a.mime == offer || a.mime == mimeType(offer)+"/*" {
+				return offer
+			}
+		}
+	}
+	return defaultOffer
+}
+
+func mimeType(mime string) string {
+	if i := strings.IndexByte(mime, '/'); i >= 0 {
+		return mime[:i]
+	}
+	return mime
+}
@@ -0,0 +1,93 @@
+// Package origin implements the Origin-matching rules shared by
+// middleware/cors and the websocket subpackage, so the two can't silently
+// diverge on what counts as an allowed cross-origin request.
+package origin
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Matcher decides whether an Origin header value is allowed, given a list
+// of patterns that may contain a single "*" wildcard (e.g.
+// "http://*.domain.com") or be a regular expression wrapped in "/"
+// (e.g. "/^https://.*\.domain\.com$/"). A literal "*" allows every origin.
+type Matcher struct {
+	allowAll  bool
+	exact     []string
+	wildcards []wildcard
+	regexps   []*regexp.Regexp
+}
+
+// New builds a Matcher from patterns. An empty patterns list builds a
+// Matcher that allows nothing; callers that want a permissive default
+// should handle the empty case themselves (see middleware/cors.Default
+// and websocket.Upgrader).
+func New(patterns []string) *Matcher {
+	m := &Matcher{}
+	for _, p := range patterns {
+		switch {
+		case p == "*":
+			m.allowAll = true
+		case strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") && len(p) > 1:
+			// Compiled as written: lowercasing here would silently rewrite
+			// the author's regex (e.g. a "[A-Z]" class), changing its
+			// semantics without any indication.
+			m.regexps = append(m.regexps, regexp.MustCompile(p[1:len(p)-1]))
+		case strings.Contains(p, "*"):
+			m.wildcards = append(m.wildcards, newWildcard(strings.ToLower(p)))
+		default:
+			m.exact = append(m.exact, strings.ToLower(p))
+		}
+	}
+	return m
+}
+
+// Allowed reports whether origin matches the Matcher's patterns.
+func (m *Matcher) Allowed(origin string) bool {
+	if m.allowAll {
+		return true
+	}
+	o := strings.ToLower(origin)
+	for _, allowed := range m.exact {
+		if allowed == o {
+			return true
+		}
+	}
+	for _, w := range m.wildcards {
+		if w.match(o) {
+			return true
+		}
+	}
+	// Regexps are matched against the origin as received: the subject case
+	// is the author's to decide (e.g. via "(?i)"), same as the pattern.
+	for _, re := range m.regexps {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowAll reports whether the Matcher was built from a literal "*" and so
+// allows every origin without inspecting it.
+func (m *Matcher) AllowAll() bool {
+	return m.allowAll
+}
+
+// wildcard matches a string against a pattern that contains a single "*",
+// e.g. "http://*.domain.com".
+type wildcard struct {
+	prefix string
+	suffix string
+}
+
+func newWildcard(pattern string) wildcard {
+	parts := strings.SplitN(pattern, "*", 2)
+	return wildcard{prefix: parts[0], suffix: parts[1]}
+}
+
+func (w wildcard) match(s string) bool {
+	return len(s) >= len(w.prefix)+len(w.suffix) &&
+		strings.HasPrefix(s, w.prefix) && strings.HasSuffix(s, w.suffix)
+}
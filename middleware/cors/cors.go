@@ -0,0 +1,209 @@
+// Package cors implements a CORS middleware for gear.
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/KaiwenWang/gear"
+	"github.com/KaiwenWang/gear/internal/origin"
+)
+
+// Options is used to configure the CORS middleware.
+type Options struct {
+	// AllowedOrigins is a list of origins a cross-domain request is allowed
+	// from. An origin may contain a wildcard (*) to replace 0 or more
+	// characters (i.e.: http://*.domain.com), or be a regular expression
+	// wrapped in "/" (i.e.: /^https://.*\.domain\.com$/). Default: ["*"].
+	AllowedOrigins []string
+	// AllowedMethods is a list of methods the client is allowed to use with
+	// cross-domain requests. Default: simple methods (GET, POST, HEAD).
+	AllowedMethods []string
+	// AllowedHeaders is a list of non simple headers the client is allowed
+	// to use with cross-domain requests.
+	AllowedHeaders []string
+	// ExposedHeaders indicates which headers are safe to expose to the
+	// API of a CORS API specification.
+	ExposedHeaders []string
+	// AllowCredentials indicates whether the request can include user
+	// credentials like cookies, HTTP authentication or client side SSL
+	// certificates.
+	AllowCredentials bool
+	// MaxAge indicates how long (in seconds) the results of a preflight
+	// request can be cached. Default: 0, meaning no "Access-Control-Max-Age"
+	// header is sent, the client uses its own default.
+	MaxAge int
+	// OptionsPassthrough instructs the middleware to let the OPTIONS
+	// preflight request fall through to the next middleware, instead of
+	// responding to it directly.
+	OptionsPassthrough bool
+	// Debug enables logging debug information through App.Error.
+	Debug bool
+}
+
+type cors struct {
+	origins          *origin.Matcher
+	allowedMethods   string
+	allowedHeaders   []string
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
+	passthrough      bool
+	debug            bool
+}
+
+// New creates a gear.Middleware that handles CORS requests according to
+// the given Options. Mount it before any routes that should be reachable
+// cross-origin:
+//
+//  app.Use(cors.New(cors.Options{
+//  	AllowedOrigins: []string{"https://example.com"},
+//  }))
+func New(opts Options) gear.Middleware {
+	c := &cors{
+		allowedHeaders:   normalize(opts.AllowedHeaders),
+		allowCredentials: opts.AllowCredentials,
+		passthrough:      opts.OptionsPassthrough,
+		debug:            opts.Debug,
+	}
+
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodHead}
+	}
+	c.allowedMethods = strings.Join(normalizeMethods(methods), ", ")
+
+	if len(opts.ExposedHeaders) > 0 {
+		c.exposedHeaders = strings.Join(normalize(opts.ExposedHeaders), ", ")
+	}
+	if opts.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(opts.MaxAge)
+	}
+
+	origins := opts.AllowedOrigins
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
+	c.origins = origin.New(origins)
+
+	return c.handle
+}
+
+// Default returns a CORS middleware with permissive defaults: all origins,
+// GET/POST/HEAD methods and no credentials. It is meant for APIs that are
+// meant to be publicly consumable from any origin.
+func Default() gear.Middleware {
+	return New(Options{})
+}
+
+// Handler returns a gear.Handler that applies CORS to a single route, for
+// use with app.UseHandler or any router that accepts a gear.Handler.
+func Handler(opts Options) gear.Handler {
+	c := New(opts)
+	return handlerFunc(c)
+}
+
+type handlerFunc gear.Middleware
+
+func (h handlerFunc) Serve(ctx *gear.Context) error {
+	return h(ctx)
+}
+
+func (c *cors) handle(ctx *gear.Context) error {
+	origin := ctx.GetHeader(gear.HeaderOrigin)
+	if origin == "" {
+		return nil
+	}
+
+	res := ctx.Res
+	res.Header().Add(gear.HeaderVary, gear.HeaderOrigin)
+
+	if !c.origins.Allowed(origin) {
+		if c.debug {
+			ctx.App().Error(gear.NewAppError("cors: origin not allowed: " + origin))
+		}
+		if ctx.Method == http.MethodOptions && !c.passthrough {
+			ctx.End(http.StatusNoContent)
+		}
+		return nil
+	}
+
+	if ctx.Method != http.MethodOptions {
+		c.setSimpleHeaders(ctx, origin)
+		return nil
+	}
+
+	// preflight request
+	res.Header().Add(gear.HeaderVary, gear.HeaderAccessControlRequestMethod)
+	res.Header().Add(gear.HeaderVary, gear.HeaderAccessControlRequestHeaders)
+	c.setPreflightHeaders(ctx, origin)
+	if c.passthrough {
+		return nil
+	}
+	ctx.End(http.StatusNoContent)
+	return nil
+}
+
+func (c *cors) setSimpleHeaders(ctx *gear.Context, origin string) {
+	res := ctx.Res
+	if c.origins.AllowAll() && !c.allowCredentials {
+		res.Header().Set(gear.HeaderAccessControlAllowOrigin, "*")
+	} else {
+		res.Header().Set(gear.HeaderAccessControlAllowOrigin, origin)
+	}
+	if c.allowCredentials {
+		res.Header().Set(gear.HeaderAccessControlAllowCredentials, "true")
+	}
+	if c.exposedHeaders != "" {
+		res.Header().Set(gear.HeaderAccessControlExposeHeaders, c.exposedHeaders)
+	}
+}
+
+func (c *cors) setPreflightHeaders(ctx *gear.Context, origin string) {
+	res := ctx.Res
+	if c.origins.AllowAll() && !c.allowCredentials {
+		res.Header().Set(gear.HeaderAccessControlAllowOrigin, "*")
+	} else {
+		res.Header().Set(gear.HeaderAccessControlAllowOrigin, origin)
+	}
+	if c.allowCredentials {
+		res.Header().Set(gear.HeaderAccessControlAllowCredentials, "true")
+	}
+	res.Header().Set(gear.HeaderAccessControlAllowMethods, c.allowedMethods)
+
+	headers := c.allowedHeaders
+	if len(headers) == 0 {
+		if reqHeaders := ctx.GetHeader(gear.HeaderAccessControlRequestHeaders); reqHeaders != "" {
+			res.Header().Set(gear.HeaderAccessControlAllowHeaders, reqHeaders)
+		}
+	} else {
+		res.Header().Set(gear.HeaderAccessControlAllowHeaders, strings.Join(headers, ", "))
+	}
+	if c.maxAge != "" {
+		res.Header().Set(gear.HeaderAccessControlMaxAge, c.maxAge)
+	}
+}
+
+// normalize canonicalizes a list of header names (e.g. "content-type" ->
+// "Content-Type"), for use in Access-Control-Allow/Expose-Headers.
+func normalize(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = http.CanonicalHeaderKey(strings.TrimSpace(v))
+	}
+	return out
+}
+
+// normalizeMethods upper-cases a list of HTTP methods for use in
+// Access-Control-Allow-Methods. Unlike header names, method names are
+// matched byte-for-byte by the Fetch/CORS spec, so they must not go
+// through normalize's header-casing logic (which would turn e.g. "DELETE"
+// into "Delete" and make a case-sensitive preflight check fail).
+func normalizeMethods(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToUpper(strings.TrimSpace(v))
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package cors
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/KaiwenWang/gear"
+)
+
+func startCORSApp(t *testing.T, opts Options) *gear.ServerListener {
+	t.Helper()
+	app := gear.New()
+	app.Use(New(opts))
+	app.Use(func(ctx *gear.Context) error {
+		return ctx.End(http.StatusOK)
+	})
+	return app.Start()
+}
+
+func preflight(t *testing.T, addr, reqMethod string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodOptions, "http://"+addr+"/", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set(gear.HeaderOrigin, "https://example.com")
+	req.Header.Set(gear.HeaderAccessControlRequestMethod, reqMethod)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	return resp
+}
+
+// TestPreflightAllowedMethodsCasing verifies that Access-Control-Allow-Methods
+// is sent upper-cased regardless of the case AllowedMethods was configured
+// with, since the Fetch/CORS spec matches methods byte-for-byte and a
+// header-casing transform (e.g. "DELETE" -> "Delete") would make a real
+// browser's case-sensitive preflight check fail.
+func TestPreflightAllowedMethodsCasing(t *testing.T) {
+	sl := startCORSApp(t, Options{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedMethods: []string{"get", "post", "delete"},
+	})
+	defer sl.Close()
+
+	resp := preflight(t, sl.Addr().String(), "DELETE")
+	defer resp.Body.Close()
+
+	got := resp.Header.Get(gear.HeaderAccessControlAllowMethods)
+	want := "GET, POST, DELETE"
+	if got != want {
+		t.Fatalf("%s = %q, want %q", gear.HeaderAccessControlAllowMethods, got, want)
+	}
+}
+
+// TestPreflightDefaultMethods covers the default AllowedMethods list.
+func TestPreflightDefaultMethods(t *testing.T) {
+	sl := startCORSApp(t, Options{AllowedOrigins: []string{"*"}})
+	defer sl.Close()
+
+	resp := preflight(t, sl.Addr().String(), "GET")
+	defer resp.Body.Close()
+
+	got := resp.Header.Get(gear.HeaderAccessControlAllowMethods)
+	want := "GET, POST, HEAD"
+	if got != want {
+		t.Fatalf("%s = %q, want %q", gear.HeaderAccessControlAllowMethods, got, want)
+	}
+}
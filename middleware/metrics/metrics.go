@@ -0,0 +1,128 @@
+// Package metrics implements a Prometheus instrumentation middleware for
+// gear.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/KaiwenWang/gear"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Options configures New.
+type Options struct {
+	// Namespace and Subsystem prefix the exposed metric names.
+	Namespace string
+	Subsystem string
+	// Path is where Handler serves the Prometheus exposition format.
+	// Default: "/metrics".
+	Path string
+	// Skipper, when it returns true, skips instrumentation for that
+	// request. Path is always skipped.
+	Skipper func(*gear.Context) bool
+	// Registerer registers the collectors. Default: prometheus.DefaultRegisterer.
+	Registerer prometheus.Registerer
+	// Gatherer is used by Handler to collect metrics. Default: prometheus.DefaultGatherer.
+	Gatherer prometheus.Gatherer
+}
+
+// Metrics instruments requests handled by an App and exposes them in the
+// Prometheus exposition format.
+type Metrics struct {
+	opts     Options
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+	handler  gear.Middleware
+}
+
+// New creates Metrics from opts, registering its collectors with
+// opts.Registerer.
+func New(opts Options) *Metrics {
+	if opts.Path == "" {
+		opts.Path = "/metrics"
+	}
+	reg := opts.Registerer
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	gatherer := opts.Gatherer
+	if gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+
+	labels := []string{"method", "route", "code"}
+	m := &Metrics{
+		opts: opts,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Subsystem: opts.Subsystem,
+			Name:      "http_response_size_bytes",
+			Help:      "HTTP response size in bytes.",
+			Buckets:   prometheus.ExponentialBuckets(100, 10, 6),
+		}, labels),
+	}
+	reg.MustRegister(m.requests, m.duration, m.size)
+	m.handler = gear.WrapHandlerFunc(promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}).ServeHTTP)
+	return m
+}
+
+// Middleware returns the gear.Middleware that records
+// http_requests_total, http_request_duration_seconds and
+// http_response_size_bytes for every request.
+func (m *Metrics) Middleware(ctx *gear.Context) error {
+	if ctx.Req.URL.Path == m.opts.Path || (m.opts.Skipper != nil && m.opts.Skipper(ctx)) {
+		return nil
+	}
+
+	start := time.Now()
+	method := ctx.Req.Method
+	// Gear has no built-in router to resolve a route template from, so the
+	// raw request path is used as the label; apps with parameterized paths
+	// should front this with a router-aware Skipper or fork the label to
+	// avoid unbounded cardinality.
+	route := ctx.Req.URL.Path
+
+	ctx.After(func(c *gear.Context) {
+		code := strconv.Itoa(c.Res.Status)
+		m.requests.WithLabelValues(method, route, code).Inc()
+		m.duration.WithLabelValues(method, route, code).Observe(time.Since(start).Seconds())
+		if size, err := strconv.ParseFloat(c.Res.Header().Get(gear.HeaderContentLength), 64); err == nil {
+			m.size.WithLabelValues(method, route, code).Observe(size)
+		}
+	})
+	return nil
+}
+
+// Handler returns a gear.Handler that serves the Prometheus exposition
+// format at Options.Path; mount it with app.UseHandler ahead of Middleware.
+func (m *Metrics) Handler() gear.Handler {
+	path := m.opts.Path
+	handler := m.handler
+	return handlerFunc(func(ctx *gear.Context) error {
+		if ctx.Req.URL.Path != path {
+			return nil
+		}
+		ctx.End(0)
+		return handler(ctx)
+	})
+}
+
+type handlerFunc gear.Middleware
+
+func (h handlerFunc) Serve(ctx *gear.Context) error { return h(ctx) }
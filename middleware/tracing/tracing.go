@@ -0,0 +1,82 @@
+// Package tracing implements an OpenTelemetry request tracing middleware
+// for gear.
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/KaiwenWang/gear"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options configures New.
+type Options struct {
+	// TracerName names the tracer obtained via otel.Tracer. Default: "gear".
+	TracerName string
+	// Propagator extracts the trace context from incoming request headers.
+	// Default: propagation.TraceContext{}, i.e. the W3C traceparent header.
+	Propagator propagation.TextMapPropagator
+	// Skipper, when it returns true, skips tracing for that request.
+	Skipper func(*gear.Context) bool
+}
+
+type headerCarrier http.Header
+
+func (c headerCarrier) Get(key string) string { return http.Header(c).Get(key) }
+func (c headerCarrier) Set(key, value string) { http.Header(c).Set(key, value) }
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// New returns a gear.Middleware that starts an OpenTelemetry span for every
+// request, continuing any trace propagated via the incoming W3C
+// traceparent header. The span is stashed on ctx via ctx.WithSpan, and
+// ended once the response has been written, with the route and final
+// status code recorded on it.
+func New(opts Options) gear.Middleware {
+	name := opts.TracerName
+	if name == "" {
+		name = "gear"
+	}
+	tracer := otel.Tracer(name)
+	prop := opts.Propagator
+	if prop == nil {
+		prop = propagation.TraceContext{}
+	}
+
+	return func(ctx *gear.Context) error {
+		if opts.Skipper != nil && opts.Skipper(ctx) {
+			return nil
+		}
+
+		route := ctx.Req.URL.Path
+		parent := prop.Extract(ctx.Req.Context(), headerCarrier(ctx.Req.Header))
+		spanCtx, span := tracer.Start(parent, route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", ctx.Req.Method),
+				attribute.String("http.route", route),
+			),
+		)
+
+		ctx.Req = ctx.Req.WithContext(spanCtx)
+		ctx.WithSpan(span)
+
+		ctx.After(func(c *gear.Context) {
+			defer span.End()
+			span.SetAttributes(attribute.Int("http.status_code", c.Res.Status))
+			if c.Res.Status >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(c.Res.Status))
+			}
+		})
+		return nil
+	}
+}
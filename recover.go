@@ -0,0 +1,110 @@
+package gear
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"runtime"
+	"strings"
+)
+
+// RecoverOptions configures how App recovers from a panic raised while
+// processing a request. See App.Recover.
+type RecoverOptions struct {
+	// StackSize is the maximum number of bytes to capture for the stack
+	// trace built from the panicking goroutine. Default: 4096.
+	StackSize int
+	// DisablePrintStack disables writing the captured stack trace to
+	// App.Error. Useful in production, where RecoverHandler can ship the
+	// stack to an external crash reporter instead.
+	DisablePrintStack bool
+	// DisableLog4xx disables routing panics that RecoverHandler maps to a
+	// 4xx status through App.Error. By default, unlike ordinary middleware
+	// errors (which only report 5xx to App.Error), every recovered panic
+	// is logged because panics are always unexpected.
+	DisableLog4xx bool
+	// RecoverHandler, when set, is called with the recovered panic value
+	// and its stack trace, and decides the *Error to respond with. When it
+	// returns nil, or RecoverHandler itself is nil, the panic is converted
+	// to a generic 500 Internal Server Error, matching Gear's historical
+	// behavior.
+	RecoverHandler func(ctx *Context, err interface{}, stack []byte) *Error
+}
+
+// PanicError wraps a value recovered from a panic so it flows through
+// ParseError like any other HTTPError.
+type PanicError struct {
+	Err   interface{}
+	Stack []byte
+}
+
+// Error implements error.
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic recovered: %v", p.Err)
+}
+
+// Status implements HTTPError. A panic always defaults to 500; use
+// RecoverOptions.RecoverHandler to map specific panics to another status.
+func (p *PanicError) Status() int {
+	return 500
+}
+
+// recover turns a recovered panic value into a response on ctx, following
+// app.Recover's configuration. It's called from serveHandler.ServeHTTP's
+// top-level defer.
+func (app *App) recover(ctx *Context, rec interface{}) {
+	opts := app.Recover
+	size := opts.StackSize
+	if size <= 0 {
+		size = 4096
+	}
+	stack := panicStack(size)
+
+	var err *Error
+	if opts.RecoverHandler != nil {
+		err = opts.RecoverHandler(ctx, rec, stack)
+	}
+	if err == nil {
+		// Matches Gear's historical behavior: the real panic value only
+		// ever reaches the server log, never the client, since it may
+		// carry internal state or error text the caller shouldn't see.
+		err = &Error{Code: 500, Msg: http.StatusText(500)}
+	}
+	ctx.Error(err)
+
+	if ctx.Res.Status >= 500 || !opts.DisableLog4xx {
+		// PanicError.Error() carries the raw panic value; it's only ever
+		// written to the server log here, never to ctx (see above).
+		logErr := &PanicError{Err: rec, Stack: stack}
+		httprequest, _ := httputil.DumpRequest(ctx.Req, false)
+		msg := fmt.Sprintf("%s; %s", logErr.Error(),
+			strings.Replace(string(httprequest), "\n", "\\n", -1))
+		if !opts.DisablePrintStack {
+			msg = fmt.Sprintf("%s\n%s", msg, stack)
+		}
+		app.Error(fmt.Errorf("%s", msg))
+	}
+}
+
+// panicStack builds a symbolic stack trace for the goroutine that is
+// currently panicking, capped at size bytes.
+func panicStack(size int) []byte {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more || b.Len() >= size {
+			break
+		}
+	}
+
+	out := b.String()
+	if len(out) > size {
+		out = out[:size]
+	}
+	return []byte(out)
+}
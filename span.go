@@ -0,0 +1,19 @@
+package gear
+
+import "context"
+
+type spanKey struct{}
+
+// WithSpan attaches an arbitrary tracing span to ctx's request context, so
+// later middleware and handlers can retrieve it with Span. Gear itself
+// doesn't depend on any tracing library; this is the hook
+// gear/middleware/tracing uses to stash an OpenTelemetry span.
+func (ctx *Context) WithSpan(span interface{}) {
+	ctx.Req = ctx.Req.WithContext(context.WithValue(ctx.Req.Context(), spanKey{}, span))
+}
+
+// Span returns the value previously attached with WithSpan, or nil if none
+// was attached.
+func (ctx *Context) Span() interface{} {
+	return ctx.Req.Context().Value(spanKey{})
+}
@@ -0,0 +1,57 @@
+package gear
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// ListenH2C starts a cleartext HTTP/2 (h2c) server, so that clients that
+// speak HTTP/2 directly over TCP (without TLS), such as some gRPC clients,
+// can talk to the app without a proxy in front of it.
+func (app *App) ListenH2C(addr string) error {
+	app.Server.Addr = addr
+	app.Server.Handler = h2c.NewHandler(app.toServeHandler(), &http2.Server{})
+	if app.ErrorLog != nil {
+		app.Server.ErrorLog = app.ErrorLog
+	}
+	return app.Server.ListenAndServe()
+}
+
+// ListenAutoTLS starts the HTTPS server with certificates automatically
+// obtained and renewed from Let's Encrypt through autocert.Manager. The
+// ACME HTTP-01 challenge is served on :80, so the process must be allowed
+// to bind that port. cacheDir, when non-empty, enables an autocert.DirCache
+// so certificates survive restarts. The challenge server is stopped
+// alongside the main one by App.Shutdown/App.Close.
+func (app *App) ListenAutoTLS(addr string, hostPolicy autocert.HostPolicy, cacheDir string) error {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: hostPolicy,
+	}
+	if cacheDir != "" {
+		m.Cache = autocert.DirCache(cacheDir)
+	}
+
+	app.Server.Addr = addr
+	app.Server.Handler = app.toServeHandler()
+	app.Server.TLSConfig = m.TLSConfig()
+	if app.ErrorLog != nil {
+		app.Server.ErrorLog = app.ErrorLog
+	}
+
+	app.acmeServer = &http.Server{Addr: ":80", Handler: m.HTTPHandler(nil)}
+	go func() {
+		// best effort: serve the ACME HTTP-01 challenge and redirect
+		// everything else to HTTPS. Stopped alongside app.Server by
+		// App.Shutdown/App.Close, so it doesn't leak for the process
+		// lifetime once Listen is torn down.
+		if err := app.acmeServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			app.Error(NewAppError(fmt.Sprintf("autocert challenge server: %s", err)))
+		}
+	}()
+	return app.Server.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,114 @@
+// Package websocket provides WebSocket upgrade support for gear.Context,
+// built on top of gorilla/websocket.
+package websocket
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/KaiwenWang/gear/internal/origin"
+)
+
+// Conn wraps the gorilla/websocket connection established by Upgrade.
+type Conn struct {
+	*websocket.Conn
+	onClose     func()
+	onCloseOnce sync.Once
+}
+
+// OnClose registers fn to run once Close is called on the connection. gear
+// uses this to release a Context upgraded via ctx.Upgrade back to its
+// App's pool only once the socket is actually done with, rather than as
+// soon as the upgrading middleware returns.
+func (c *Conn) OnClose(fn func()) {
+	c.onClose = fn
+}
+
+// Close closes the underlying connection, then runs the hook registered
+// via OnClose, if any. The hook runs at most once even if Close is called
+// more than once — a common pattern for handlers with separate read/write
+// goroutines that each Close on their own error path — since it releases
+// the upgraded Context back to its App's pool, and running it twice would
+// hand the same Context to two concurrent unrelated requests.
+func (c *Conn) Close() error {
+	err := c.Conn.Close()
+	if c.onClose != nil {
+		c.onCloseOnce.Do(c.onClose)
+	}
+	return err
+}
+
+// UpgradeOptions configures an Upgrader.
+type UpgradeOptions struct {
+	// Subprotocols lists the server's supported subprotocols, in
+	// preference order. The first one also present in the client's
+	// Sec-WebSocket-Protocol header is selected and echoed back.
+	Subprotocols []string
+	// AllowedOrigins is a list of origins allowed to open a WebSocket
+	// connection, sharing the wildcard/regexp matching rules of
+	// cors.Options.AllowedOrigins (see internal/origin). An empty list
+	// falls back to gorilla/websocket's default same-origin check, not to
+	// allowing every origin.
+	AllowedOrigins []string
+	// HandshakeTimeout bounds the opening handshake. Default: 10s.
+	HandshakeTimeout time.Duration
+	// ReadDeadline and WriteDeadline, when non-zero, are applied to the
+	// Conn immediately after a successful upgrade.
+	ReadDeadline  time.Duration
+	WriteDeadline time.Duration
+}
+
+// Upgrader upgrades HTTP connections to WebSocket according to its
+// UpgradeOptions.
+type Upgrader struct {
+	opts     UpgradeOptions
+	origins  *origin.Matcher
+	upgrader websocket.Upgrader
+}
+
+// New creates an Upgrader from opts.
+func New(opts UpgradeOptions) *Upgrader {
+	u := &Upgrader{opts: opts}
+	handshakeTimeout := opts.HandshakeTimeout
+	if handshakeTimeout <= 0 {
+		handshakeTimeout = 10 * time.Second
+	}
+	u.upgrader = websocket.Upgrader{
+		HandshakeTimeout: handshakeTimeout,
+		Subprotocols:     opts.Subprotocols,
+	}
+	if len(opts.AllowedOrigins) > 0 {
+		u.origins = origin.New(opts.AllowedOrigins)
+		u.upgrader.CheckOrigin = u.checkOrigin
+	}
+	// else: leave CheckOrigin nil, so gorilla/websocket falls back to its
+	// own same-origin check instead of allowing every origin.
+	return u
+}
+
+// Upgrade hijacks w and upgrades the connection to WebSocket, applying the
+// configured read/write deadlines before returning the Conn.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*Conn, error) {
+	c, err := u.upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		return nil, err
+	}
+	if u.opts.ReadDeadline > 0 {
+		c.SetReadDeadline(time.Now().Add(u.opts.ReadDeadline))
+	}
+	if u.opts.WriteDeadline > 0 {
+		c.SetWriteDeadline(time.Now().Add(u.opts.WriteDeadline))
+	}
+	return &Conn{Conn: c}, nil
+}
+
+func (u *Upgrader) checkOrigin(r *http.Request) bool {
+	o := r.Header.Get("Origin")
+	if o == "" {
+		return true
+	}
+	return u.origins.Allowed(o)
+}
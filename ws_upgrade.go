@@ -0,0 +1,24 @@
+package gear
+
+import "github.com/KaiwenWang/gear/websocket"
+
+// Upgrade hijacks the underlying connection and upgrades it to WebSocket
+// according to opts. It marks ctx as ended so serveHandler.ServeHTTP's
+// post-processing (ctx.Res.respond and afterHooks) is skipped, and claims
+// ctx's release-to-pool callback so serveHandler.ServeHTTP's defer no
+// longer runs it: instead it's wired to conn's OnClose, so the pooled
+// Context is only returned to app.pool once conn is actually closed,
+// rather than as soon as the caller's middleware returns (the common
+// pattern being to hand conn off to a read/write goroutine and return nil
+// immediately).
+func (ctx *Context) Upgrade(opts websocket.UpgradeOptions) (*websocket.Conn, error) {
+	conn, err := websocket.New(opts).Upgrade(ctx.Res, ctx.Req, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx.ended = true
+	if release, ok := takeRelease(ctx); ok {
+		conn.OnClose(release)
+	}
+	return conn, nil
+}